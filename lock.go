@@ -0,0 +1,152 @@
+package mfs
+
+import (
+	"fmt"
+	"sync"
+)
+
+// heldRange is one advisory lock held by a FileDescriptor over a byte range
+// of a File, mirroring the semantics of POSIX F_SETLK/F_SETLKW.
+type heldRange struct {
+	off    int64
+	length int64 // 0 means "to end of file"
+	excl   bool
+	owner  *fileDescriptor
+}
+
+func (r heldRange) end() int64 {
+	if r.length == 0 {
+		return 1<<63 - 1
+	}
+	return r.off + r.length
+}
+
+func (r heldRange) overlaps(off, length int64) bool {
+	end := off + length
+	if length == 0 {
+		end = 1<<63 - 1
+	}
+	return r.off < end && off < r.end()
+}
+
+// rangeLockTable tracks the advisory locks held over a single File's byte
+// ranges. It plays the role of an interval tree: for the small number of
+// ranges any one file typically has locked at once, a scan is simpler and
+// just as fast.
+type rangeLockTable struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	ranges []heldRange
+}
+
+func newRangeLockTable() *rangeLockTable {
+	t := &rangeLockTable{}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// conflicts reports whether [off, off+length) is held incompatibly by an
+// owner other than owner. Two shared locks never conflict; a request from
+// the same owner never conflicts with its own prior grants, so that
+// upgrades and downgrades in place can proceed without deadlocking.
+func (t *rangeLockTable) conflicts(off, length int64, excl bool, owner *fileDescriptor) bool {
+	for _, r := range t.ranges {
+		if r.owner == owner {
+			continue
+		}
+		if !r.excl && !excl {
+			continue
+		}
+		if r.overlaps(off, length) {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire installs [off, off+length) for owner, replacing any identical
+// range the same owner already held so that re-locking coalesces instead
+// of stacking duplicate grants.
+func (t *rangeLockTable) acquire(off, length int64, excl bool, owner *fileDescriptor) {
+	kept := t.ranges[:0]
+	for _, r := range t.ranges {
+		if r.owner == owner && r.off == off && r.length == length {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	t.ranges = append(kept, heldRange{off: off, length: length, excl: excl, owner: owner})
+}
+
+func (t *rangeLockTable) release(off, length int64, owner *fileDescriptor) {
+	kept := t.ranges[:0]
+	for _, r := range t.ranges {
+		if r.owner == owner && r.off == off && r.length == length {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	t.ranges = kept
+	t.cond.Broadcast()
+}
+
+// releaseAll drops every range owner holds, as happens when its
+// FileDescriptor is closed.
+func (t *rangeLockTable) releaseAll(owner *fileDescriptor) {
+	t.mu.Lock()
+	kept := t.ranges[:0]
+	for _, r := range t.ranges {
+		if r.owner == owner {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	t.ranges = kept
+	t.cond.Broadcast()
+	t.mu.Unlock()
+}
+
+// Lock acquires an advisory lock over [off, off+length) (length 0 means to
+// EOF), blocking until any incompatible overlapping range held by another
+// owner is released. excl requests an exclusive (write) lock; otherwise the
+// lock is shared (read).
+//
+// This lock is advisory only: it is tracked in rangeLockTable alongside,
+// not instead of, File.desclock. File.Open still takes the whole-file
+// desclock for any write-flagged open, so holding a Lock over one range
+// does not let a second writer obtain a write FileDescriptor over a
+// disjoint range - see the FileDescriptor doc comment.
+func (fi *fileDescriptor) Lock(off, length int64, excl bool) error {
+	t := fi.inode.locks
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.conflicts(off, length, excl, fi) {
+		t.cond.Wait()
+	}
+	t.acquire(off, length, excl, fi)
+	return nil
+}
+
+// TryLock is like Lock but returns an error immediately instead of
+// blocking if the range is held incompatibly by another owner.
+func (fi *fileDescriptor) TryLock(off, length int64, excl bool) error {
+	t := fi.inode.locks
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conflicts(off, length, excl, fi) {
+		return fmt.Errorf("range [%d, %d) is locked", off, off+length)
+	}
+	t.acquire(off, length, excl, fi)
+	return nil
+}
+
+// Unlock releases the lock this descriptor holds over exactly [off,
+// off+length), as passed to Lock or TryLock. A held range that only
+// partly overlaps [off, off+length) is neither released nor split.
+func (fi *fileDescriptor) Unlock(off, length int64) error {
+	t := fi.inode.locks
+	t.mu.Lock()
+	t.release(off, length, fi)
+	t.mu.Unlock()
+	return nil
+}
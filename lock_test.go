@@ -0,0 +1,121 @@
+package mfs
+
+import (
+	"context"
+	"testing"
+
+	dag "github.com/ipfs/go-merkledag"
+	dagtest "github.com/ipfs/go-merkledag/test"
+	ft "github.com/ipfs/go-unixfs"
+)
+
+// testFile builds a small File backed by a mock DAGService, for exercising
+// rangeLockTable through the FileDescriptor it hands out. Range locks are
+// advisory and independent of flags.Read/Write (see the FileDescriptor doc
+// comment), so every test here opens read-only descriptors: that lets
+// multiple descriptors coexist under desclock.RLock without the
+// whole-file write lock getting in the way of what's under test.
+func testFile(t *testing.T) *File {
+	t.Helper()
+	dserv := dagtest.Mock()
+	data := make([]byte, 1024)
+	nd := dag.NodeWithData(ft.FilePBData(data, uint64(len(data))))
+	if err := dserv.Add(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := NewFile("test", nd, nil, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi
+}
+
+func TestRangeLockExclusiveConflict(t *testing.T) {
+	fi := testFile(t)
+	a, err := fi.Open(Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := fi.Open(Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if err := a.Lock(0, 10, true); err != nil {
+		t.Fatalf("a.Lock: %s", err)
+	}
+
+	if err := b.TryLock(5, 10, true); err == nil {
+		t.Fatal("expected overlapping exclusive TryLock from a different owner to fail")
+	}
+
+	if err := b.TryLock(20, 10, true); err != nil {
+		t.Fatalf("disjoint TryLock should succeed: %s", err)
+	}
+}
+
+func TestRangeLockSharedDoesNotConflict(t *testing.T) {
+	fi := testFile(t)
+	a, err := fi.Open(Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := fi.Open(Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if err := a.Lock(0, 10, false); err != nil {
+		t.Fatalf("a.Lock (shared): %s", err)
+	}
+	if err := b.TryLock(5, 10, false); err != nil {
+		t.Fatalf("overlapping shared locks from different owners should not conflict: %s", err)
+	}
+}
+
+func TestRangeLockSameOwnerReacquireDoesNotConflict(t *testing.T) {
+	fi := testFile(t)
+	a, err := fi.Open(Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if err := a.Lock(0, 10, true); err != nil {
+		t.Fatalf("a.Lock: %s", err)
+	}
+	// Re-acquiring (e.g. upgrading/downgrading) the same range from the
+	// same owner must not be treated as a conflict with itself.
+	if err := a.Lock(0, 10, true); err != nil {
+		t.Fatalf("a.Lock (re-acquire): %s", err)
+	}
+}
+
+func TestRangeLockReleasedOnClose(t *testing.T) {
+	fi := testFile(t)
+	a, err := fi.Open(Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Lock(0, 10, true); err != nil {
+		t.Fatalf("a.Lock: %s", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("a.Close: %s", err)
+	}
+
+	b, err := fi.Open(Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.TryLock(0, 10, true); err != nil {
+		t.Fatalf("expected lock to be released on Close, got: %s", err)
+	}
+}
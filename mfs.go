@@ -0,0 +1,59 @@
+// Package mfs implements an in-memory model of a mutable IPFS filesystem.
+//
+// It consists of these main types:
+//  1. File, read and written through a FileDescriptor
+//  2. Symlink
+//  3. shardedDir, a HAMT-backed directory helper
+//
+// It does not define Filesystem, Root or Directory types.
+package mfs
+
+import (
+	"errors"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+var ErrNotYetImplemented = errors.New("not yet implemented")
+var ErrInvalidChild = errors.New("invalid child node")
+var ErrIsDirectory = errors.New("error: is a directory")
+var ErrNotExist = errors.New("no such rootfs")
+
+// NodeType is the type of the node, either file, directory, or symlink.
+type NodeType int
+
+const (
+	TFile NodeType = iota
+	TDir
+	TSymlink
+)
+
+// FSNode represents any node (file, directory, or symlink) in the mfs filesystem.
+type FSNode interface {
+	GetNode() (ipld.Node, error)
+	Flush() error
+	Type() NodeType
+}
+
+// parent is a node that has children, and is used to allow children to
+// notify their parent of changes.
+type parent interface {
+	// closeChild is called to pass changes in a child node up to its parent.
+	closeChild(string, ipld.Node, bool) error
+	Type() NodeType
+}
+
+// childCloser is like parent, but only exposes the method a child needs to
+// call when it is closed.
+type childCloser interface {
+	closeChild(string, ipld.Node, bool) error
+}
+
+// inode is the base struct shared by both files and symlinks in mfs; it
+// holds the bits every leaf node needs regardless of what its underlying
+// UnixFS node type is.
+type inode struct {
+	name       string
+	parent     childCloser
+	dagService ipld.DAGService
+}
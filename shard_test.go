@@ -0,0 +1,155 @@
+package mfs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	dag "github.com/ipfs/go-merkledag"
+	dagtest "github.com/ipfs/go-merkledag/test"
+	ft "github.com/ipfs/go-unixfs"
+)
+
+func testLeaf(t *testing.T, data string) *dag.ProtoNode {
+	t.Helper()
+	nd := dag.NodeWithData(ft.FilePBData([]byte(data), uint64(len(data))))
+	return nd
+}
+
+// TestShardInsertLookupRemove exercises the basic HAMT-backed
+// insert/lookup/remove path in isolation from any plain directory.
+func TestShardInsertLookupRemove(t *testing.T) {
+	dserv := dagtest.Mock()
+	sd, err := newShardedDir(dserv, ShardingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	leaf := testLeaf(t, "hello")
+	if err := dserv.Add(ctx, leaf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sd.Insert(ctx, "a", leaf); err != nil {
+		t.Fatalf("insert: %s", err)
+	}
+
+	found, err := sd.Child(ctx, "a")
+	if err != nil {
+		t.Fatalf("lookup: %s", err)
+	}
+	if found.Cid() != leaf.Cid() {
+		t.Fatalf("lookup returned wrong node: %s != %s", found.Cid(), leaf.Cid())
+	}
+
+	if err := sd.Remove(ctx, "a"); err != nil {
+		t.Fatalf("remove: %s", err)
+	}
+	if _, err := sd.Child(ctx, "a"); err == nil {
+		t.Fatal("expected error looking up removed entry")
+	}
+
+	// Removing a name that was never present, or was already removed,
+	// is not an error.
+	if err := sd.Remove(ctx, "a"); err != nil {
+		t.Fatalf("remove of already-removed name should be a no-op, got: %s", err)
+	}
+	if err := sd.Remove(ctx, "never-existed"); err != nil {
+		t.Fatalf("remove of missing name should be a no-op, got: %s", err)
+	}
+}
+
+// TestShardPlainRoundTrip converts a plain directory across the sharding
+// boundary and back, checking every entry survives both conversions.
+func TestShardPlainRoundTrip(t *testing.T) {
+	dserv := dagtest.Mock()
+	ctx := context.Background()
+
+	pn := dag.NodeWithData(ft.FolderPBData())
+	names := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("file-%d", i)
+		leaf := testLeaf(t, name)
+		if err := dserv.Add(ctx, leaf); err != nil {
+			t.Fatal(err)
+		}
+		if err := pn.AddNodeLink(name, leaf); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	sd, err := shardFromPlain(ctx, dserv, pn, ShardingOptions{Threshold: 4})
+	if err != nil {
+		t.Fatalf("shardFromPlain: %s", err)
+	}
+
+	count, err := sd.entryCount(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(names) {
+		t.Fatalf("expected %d entries in shard, got %d", len(names), count)
+	}
+
+	for _, name := range names {
+		if _, err := sd.Child(ctx, name); err != nil {
+			t.Fatalf("lookup %q after shardFromPlain: %s", name, err)
+		}
+	}
+
+	back, err := sd.toPlain(ctx)
+	if err != nil {
+		t.Fatalf("toPlain: %s", err)
+	}
+	if len(back.Links()) != len(names) {
+		t.Fatalf("expected %d links after toPlain, got %d", len(names), len(back.Links()))
+	}
+}
+
+// TestShardConcurrentInserts drives many goroutines inserting distinct
+// names into the same shard at once, the scenario that forces the shard
+// to split its internal buckets concurrently, and checks every entry is
+// retrievable afterward.
+func TestShardConcurrentInserts(t *testing.T) {
+	dserv := dagtest.Mock()
+	sd, err := newShardedDir(dserv, ShardingOptions{Threshold: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	const n = 64
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("entry-%d", i)
+			leaf := testLeaf(t, name)
+			if err := dserv.Add(ctx, leaf); err != nil {
+				errs <- err
+				return
+			}
+			errs <- sd.Insert(ctx, name, leaf)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent insert: %s", err)
+		}
+	}
+
+	count, err := sd.entryCount(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != n {
+		t.Fatalf("expected %d entries after concurrent inserts, got %d", n, count)
+	}
+}
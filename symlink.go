@@ -0,0 +1,119 @@
+package mfs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// Symlink represents a symlink in the mfs filesystem, mirroring the
+// ft.TSymlink UnixFS node type. Unlike File, a Symlink has no descriptor to
+// open: its only content is the target path it points to.
+type Symlink struct {
+	*inode
+
+	node   ipld.Node
+	nodelk sync.Mutex
+}
+
+// NewSymlink creates a Symlink pointing at target and writes the backing
+// UnixFS symlink node into dserv, returning the resulting mfs node.
+func NewSymlink(name, target string, parent childCloser, dserv ipld.DAGService) (*Symlink, error) {
+	data, err := ft.SymlinkData(target)
+	if err != nil {
+		return nil, err
+	}
+
+	nd := dag.NodeWithData(data)
+	nd.SetCidBuilder(dag.V0CidPrefix())
+
+	if err := dserv.Add(context.TODO(), nd); err != nil {
+		return nil, err
+	}
+
+	return &Symlink{
+		inode: &inode{
+			name:       name,
+			parent:     parent,
+			dagService: dserv,
+		},
+		node: nd,
+	}, nil
+}
+
+// Readlink returns the target path this symlink points to.
+func (s *Symlink) Readlink() (string, error) {
+	s.nodelk.Lock()
+	defer s.nodelk.Unlock()
+
+	pn, ok := s.node.(*dag.ProtoNode)
+	if !ok {
+		return "", fmt.Errorf("unrecognized node type for symlink")
+	}
+
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return "", err
+	}
+
+	if fsn.Type() != ft.TSymlink {
+		return "", fmt.Errorf("node was not a symlink")
+	}
+
+	return string(fsn.Data()), nil
+}
+
+// SetTarget rewrites the symlink to point at target, replacing the backing
+// UnixFS node and propagating the change up to the parent.
+func (s *Symlink) SetTarget(target string) error {
+	data, err := ft.SymlinkData(target)
+	if err != nil {
+		return err
+	}
+
+	nd := dag.NodeWithData(data)
+	nd.SetCidBuilder(dag.V0CidPrefix())
+
+	if err := s.dagService.Add(context.TODO(), nd); err != nil {
+		return err
+	}
+
+	s.nodelk.Lock()
+	s.node = nd
+	s.nodelk.Unlock()
+
+	return s.parent.closeChild(s.name, nd, false)
+}
+
+// GetNode returns the dag node associated with this symlink.
+func (s *Symlink) GetNode() (ipld.Node, error) {
+	s.nodelk.Lock()
+	defer s.nodelk.Unlock()
+	return s.node, nil
+}
+
+// Flush is a no-op for Symlink: every SetTarget call already persists the
+// updated node, there is nothing buffered to sync.
+func (s *Symlink) Flush() error {
+	return nil
+}
+
+// Type returns the type FSNode this is.
+func (s *Symlink) Type() NodeType {
+	return TSymlink
+}
+
+// Size returns the length of the symlink's target string, matching the
+// behavior go-ipfs-files uses for its Symlink type.
+func (s *Symlink) Size() (int64, error) {
+	target, err := s.Readlink()
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(target)), nil
+}
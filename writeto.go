@@ -0,0 +1,197 @@
+package mfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	cid "github.com/ipfs/go-cid"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// WriteTo implements io.WriterTo, streaming the file's contents to w. If
+// the descriptor was opened with Flags.ReadAhead > 0, up to that many DAG
+// children are fetched concurrently via the DAGService ahead of the
+// writer, instead of walking the DAG one block at a time on the caller's
+// goroutine.
+func (fi *fileDescriptor) WriteTo(w io.Writer) (int64, error) {
+	if !fi.flags.Read {
+		return 0, fmt.Errorf("cannot read on write-only descriptor")
+	}
+
+	if fi.flags.ReadAhead <= 0 {
+		return fi.writeToSequential(w)
+	}
+	return fi.writeToReadAhead(w, fi.flags.ReadAhead)
+}
+
+// writeToSequential is the pre-existing behavior: read through the
+// DagModifier one buffer at a time.
+func (fi *fileDescriptor) writeToSequential(w io.Writer) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		n, err := fi.mod.Read(buf)
+		if n > 0 {
+			nw, werr := w.Write(buf[:n])
+			written += int64(nw)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// dagFetch is the result of fetching one DAG child, delivered over a
+// promise channel so the consumer can block on children in order while
+// later siblings keep fetching in the background.
+type dagFetch struct {
+	nd  ipld.Node
+	err error
+}
+
+// writeToReadAhead streams fi's content starting at the descriptor's
+// current cursor position (matching writeToSequential) by walking the
+// file's UnixFS DAG depth-first and writing leaves to w in order, while
+// prefetching up to readAhead DAG children concurrently through a
+// semaphore-bounded pipeline: the promise for child i+1 is already
+// in flight while the writer is still consuming child i, instead of
+// fetching and writing each child in lockstep. It recurses into
+// intermediate (non-leaf) children, since every multi-level file DAG has
+// them once a file is more than a single level's worth of chunks.
+func (fi *fileDescriptor) writeToReadAhead(w io.Writer, readAhead int) (int64, error) {
+	start, err := fi.mod.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	nd, err := fi.mod.GetNode()
+	if err != nil {
+		return 0, err
+	}
+
+	// cancel stops every in-flight and not-yet-dispatched prefetch the
+	// moment this function returns for any reason - an error from a
+	// sibling fetch, a walk error, or a w.Write failure - instead of
+	// leaving the producer goroutines below to keep pulling the rest of
+	// their jobs off a consumer nobody is reading from anymore.
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	sem := make(chan struct{}, readAhead)
+	var written int64
+
+	var walk func(nd ipld.Node, offset int64) error
+	walk = func(nd ipld.Node, offset int64) error {
+		switch n := nd.(type) {
+		case *dag.RawNode:
+			return writeLeaf(w, n.RawData(), offset, &written)
+		case *dag.ProtoNode:
+			fsn, err := ft.FSNodeFromBytes(n.Data())
+			if err != nil {
+				return err
+			}
+
+			links := n.Links()
+			if len(links) == 0 {
+				return writeLeaf(w, fsn.Data(), offset, &written)
+			}
+
+			// Use the recorded per-child subtree sizes to skip whole
+			// children that end before offset without fetching them,
+			// the way a Seek on a sequential reader would.
+			type job struct {
+				c         cid.Cid
+				subOffset int64
+			}
+			var jobs []job
+			var childStart int64
+			for i, l := range links {
+				childEnd := childStart + int64(fsn.BlockSize(i))
+				if childEnd > offset {
+					sub := offset - childStart
+					if sub < 0 {
+						sub = 0
+					}
+					jobs = append(jobs, job{c: l.Cid, subOffset: sub})
+				}
+				childStart = childEnd
+			}
+			if len(jobs) == 0 {
+				return nil
+			}
+
+			promises := make([]chan dagFetch, len(jobs))
+			for i := range promises {
+				promises[i] = make(chan dagFetch, 1)
+			}
+			go func() {
+				for i, j := range jobs {
+					select {
+					case sem <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+					go func(i int, c cid.Cid) {
+						defer func() { <-sem }()
+						child, err := fi.inode.dagService.Get(ctx, c)
+						promises[i] <- dagFetch{nd: child, err: err}
+					}(i, j.c)
+				}
+			}()
+
+			for i, j := range jobs {
+				select {
+				case f := <-promises[i]:
+					if f.err != nil {
+						return f.err
+					}
+					if err := walk(f.nd, j.subOffset); err != nil {
+						return err
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		default:
+			return fmt.Errorf("unrecognized node type in file DAG")
+		}
+	}
+
+	if err := walk(nd, start); err != nil {
+		return written, err
+	}
+
+	// Leave the DagModifier's cursor at the same place a full
+	// writeToSequential read would: past everything just written.
+	if _, err := fi.mod.Seek(start+written, io.SeekStart); err != nil {
+		return written, err
+	}
+
+	return written, nil
+}
+
+// writeLeaf writes data to w, skipping offset bytes from its start first
+// (offset is non-zero only for the first leaf of a WriteTo that began
+// mid-file), and accumulates the number of bytes written into *written.
+func writeLeaf(w io.Writer, data []byte, offset int64, written *int64) error {
+	if offset > 0 {
+		if offset >= int64(len(data)) {
+			return nil
+		}
+		data = data[offset:]
+	}
+	nw, err := w.Write(data)
+	*written += int64(nw)
+	return err
+}
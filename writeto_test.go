@@ -0,0 +1,250 @@
+package mfs
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	dag "github.com/ipfs/go-merkledag"
+	dagtest "github.com/ipfs/go-merkledag/test"
+	ft "github.com/ipfs/go-unixfs"
+
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// buildMultiLevelFile assembles a two-level UnixFS file DAG: the root
+// links to numIntermediates TFile nodes, each of which links to
+// leavesPerIntermediate raw leaves of leafSize bytes. Every byte in leaf i
+// (numbered globally across the whole file) is set to byte(i % 256), so a
+// reader can check correctness without keeping the whole file in memory.
+// The total size is chosen to be well past a single top level's worth of
+// chunks, the case that truncated to nothing before this fix.
+func buildMultiLevelFile(t *testing.T, dserv ipld.DAGService, numIntermediates, leavesPerIntermediate, leafSize int) (*dag.ProtoNode, int64) {
+	t.Helper()
+	ctx := context.Background()
+
+	leafIdx := 0
+	root := ft.NewFSNode(ft.TFile)
+	rootPB := dag.NodeWithData(nil)
+	var total int64
+
+	for i := 0; i < numIntermediates; i++ {
+		mid := ft.NewFSNode(ft.TFile)
+		midPB := dag.NodeWithData(nil)
+		var midSize int64
+
+		for j := 0; j < leavesPerIntermediate; j++ {
+			data := make([]byte, leafSize)
+			for k := range data {
+				data[k] = byte(leafIdx % 256)
+			}
+			leaf := dag.NewRawNode(data)
+			if err := dserv.Add(ctx, leaf); err != nil {
+				t.Fatal(err)
+			}
+			if err := midPB.AddNodeLink("", leaf); err != nil {
+				t.Fatal(err)
+			}
+			mid.AddBlockSize(uint64(leafSize))
+			midSize += int64(leafSize)
+			leafIdx++
+		}
+
+		midData, err := mid.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		midPB.SetData(midData)
+		if err := dserv.Add(ctx, midPB); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := rootPB.AddNodeLink("", midPB); err != nil {
+			t.Fatal(err)
+		}
+		root.AddBlockSize(uint64(midSize))
+		total += midSize
+	}
+
+	rootData, err := root.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootPB.SetData(rootData)
+	if err := dserv.Add(ctx, rootPB); err != nil {
+		t.Fatal(err)
+	}
+
+	return rootPB, total
+}
+
+// checkingWriter verifies, without buffering the whole stream, that every
+// byte written matches buildMultiLevelFile's byte(i/leafSize % 256)
+// pattern and that no bytes are skipped or duplicated.
+type checkingWriter struct {
+	t        *testing.T
+	leafSize int64
+	pos      int64
+}
+
+func (cw *checkingWriter) Write(p []byte) (int, error) {
+	for i, b := range p {
+		off := cw.pos + int64(i)
+		want := byte((off / cw.leafSize) % 256)
+		if b != want {
+			cw.t.Fatalf("byte mismatch at offset %d: got %d want %d", off, b, want)
+		}
+	}
+	cw.pos += int64(len(p))
+	return len(p), nil
+}
+
+// TestWriteToReadAheadMultiLevelFile reproduces a file DAG deep enough
+// that its top-level links point at intermediate FSNodes, not leaves
+// (every file over a single level's worth of chunks, i.e. any real
+// multi-GB file). writeToReadAhead must recurse into those intermediate
+// nodes' children rather than write out their empty FSNode.Data().
+func TestWriteToReadAheadMultiLevelFile(t *testing.T) {
+	dserv := dagtest.Mock()
+	const (
+		leafSize              = 256 * 1024 // 256KiB
+		leavesPerIntermediate = 50         // ~12.5MB per intermediate
+		numIntermediates      = 5          // ~62.5MB total, >50MB
+	)
+
+	root, total := buildMultiLevelFile(t, dserv, numIntermediates, leavesPerIntermediate, leafSize)
+	if total < 50*1024*1024 {
+		t.Fatalf("test file too small to exercise the bug: %d bytes", total)
+	}
+
+	fi, err := NewFile("big", root, nil, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := fi.Open(Flags{Read: true, ReadAhead: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	cw := &checkingWriter{t: t, leafSize: leafSize}
+	written, err := fd.WriteTo(cw)
+	if err != nil {
+		t.Fatalf("WriteTo: %s", err)
+	}
+	if written != total {
+		t.Fatalf("expected to write %d bytes, wrote %d", total, written)
+	}
+}
+
+// TestWriteToReadAheadMidFileSeek checks that WriteTo honors the
+// descriptor's cursor (via Seek) rather than always starting from byte 0,
+// which writeToReadAhead used to ignore by reading fi.inode.node directly.
+func TestWriteToReadAheadMidFileSeek(t *testing.T) {
+	dserv := dagtest.Mock()
+	const (
+		leafSize              = 256 * 1024
+		leavesPerIntermediate = 10
+		numIntermediates      = 2
+	)
+
+	root, total := buildMultiLevelFile(t, dserv, numIntermediates, leavesPerIntermediate, leafSize)
+
+	fi, err := NewFile("mid", root, nil, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := fi.Open(Flags{Read: true, ReadAhead: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	start := int64(3 * leafSize / 2) // partway into the second leaf
+	if _, err := fd.Seek(start, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	cw := &checkingWriter{t: t, leafSize: leafSize, pos: start}
+	written, err := fd.WriteTo(cw)
+	if err != nil {
+		t.Fatalf("WriteTo: %s", err)
+	}
+	if want := total - start; written != want {
+		t.Fatalf("expected to write %d bytes from offset %d, wrote %d", want, start, written)
+	}
+}
+
+// TestWriteToReadAheadFetchErrorReturnsPromptly checks that a failed
+// fetch for one sibling leaf is surfaced as an error instead of hanging:
+// before writeToReadAhead's producer goroutines respected ctx
+// cancellation, an error on one branch left the other in-flight fetches
+// at the same level (and any deeper recursion) to run to completion with
+// nobody left reading their results.
+func TestWriteToReadAheadFetchErrorReturnsPromptly(t *testing.T) {
+	dserv := dagtest.Mock()
+	const leafSize = 256 * 1024
+
+	// One intermediate node links to a real leaf and a leaf CID that was
+	// never added to dserv, alongside several more real leaves so there
+	// is still readAhead-worth of concurrent fetches in flight when the
+	// missing one errors out.
+	mid := ft.NewFSNode(ft.TFile)
+	midPB := dag.NodeWithData(nil)
+
+	missing := dag.NewRawNode([]byte("never added"))
+	if err := midPB.AddNodeLink("", missing); err != nil {
+		t.Fatal(err)
+	}
+	mid.AddBlockSize(uint64(len(missing.RawData())))
+
+	for i := 0; i < 8; i++ {
+		data := make([]byte, leafSize)
+		leaf := dag.NewRawNode(data)
+		if err := dserv.Add(context.Background(), leaf); err != nil {
+			t.Fatal(err)
+		}
+		if err := midPB.AddNodeLink("", leaf); err != nil {
+			t.Fatal(err)
+		}
+		mid.AddBlockSize(uint64(leafSize))
+	}
+
+	midData, err := mid.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	midPB.SetData(midData)
+	if err := dserv.Add(context.Background(), midPB); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := NewFile("broken", midPB, nil, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := fi.Open(Flags{Read: true, ReadAhead: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := fd.WriteTo(io.Discard)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error from a missing leaf, got nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WriteTo did not return promptly after a fetch error")
+	}
+}